@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/kubernetes-sigs/sig-storage-lib-external-provisioner/allocator"
+	"github.com/kubernetes-sigs/sig-storage-lib-external-provisioner/gidreclaimer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// compile time check to make sure apiGIDReclaimer implements the GIDReclaimer interface
+var _ gidreclaimer.GIDReclaimer = &apiGIDReclaimer{}
+
+// gidAnnotation is the upstream Kubernetes gid annotation and is checked first;
+// pvProvisionerGIDAnnotation is the one Provision sets on every PV it creates, and is used
+// when gidAnnotation is absent.
+const (
+	gidAnnotation              = "pv.beta.kubernetes.io/gid"
+	pvProvisionerGIDAnnotation = "efs-provisioner.io/gid"
+)
+
+// apiGIDReclaimer rebuilds the GID allocator by listing PersistentVolume objects from the
+// Kubernetes API instead of walking BasePath. This avoids the O(volumes) disk I/O
+// fileSystemReclaimer pays at startup and also recovers volumes whose metadata sidecar was
+// never written. PVs with neither GID annotation are handed to fallback, so Retain-policy
+// orphans that predate both annotations are still recovered from disk.
+type apiGIDReclaimer struct {
+	client   kubernetes.Interface
+	fallback gidreclaimer.GIDReclaimer
+}
+
+func newAPIGIDReclaimer(client kubernetes.Interface, fallback gidreclaimer.GIDReclaimer) *apiGIDReclaimer {
+	return &apiGIDReclaimer{client: client, fallback: fallback}
+}
+
+// Reclaim implements gidreclaimer.GIDReclaimer.
+func (a *apiGIDReclaimer) Reclaim(classname string, gidtable *allocator.MinMaxAllocator) error {
+	glog.Infof("listing PVs for storage class %s to rebuild the gid table", classname)
+
+	pvs, err := a.client.CoreV1().PersistentVolumes().List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list PersistentVolumes: %v", err)
+	}
+
+	var anyMissingAnnotation bool
+
+	for _, pv := range pvs.Items {
+		if pv.Spec.StorageClassName != classname {
+			continue
+		}
+
+		gidStr, ok := pv.Annotations[gidAnnotation]
+		if !ok {
+			gidStr, ok = pv.Annotations[pvProvisionerGIDAnnotation]
+		}
+		if !ok {
+			anyMissingAnnotation = true
+			continue
+		}
+
+		gid, err := strconv.Atoi(gidStr)
+		if err != nil {
+			glog.Errorf("invalid gid value %q annotated on PV %s", gidStr, pv.Name)
+			continue
+		}
+
+		_, err = gidtable.Allocate(gid)
+		if err == allocator.ErrConflict {
+			glog.Infof("gid %d from PV %s was already allocated for storage class %s", gid, pv.Name, classname)
+		} else if err != nil {
+			glog.Errorf("failed to store gid %d from PV %s: %v", gid, pv.Name, err)
+		}
+	}
+
+	if anyMissingAnnotation && a.fallback != nil {
+		glog.Infof("one or more PVs for storage class %s have no gid annotation, falling back to the filesystem reclaimer", classname)
+		return a.fallback.Reclaim(classname, gidtable)
+	}
+
+	return nil
+}
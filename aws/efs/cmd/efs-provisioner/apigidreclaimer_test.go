@@ -0,0 +1,141 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kubernetes-sigs/sig-storage-lib-external-provisioner/allocator"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeGIDReclaimer records whether it was invoked, standing in for fileSystemReclaimer in
+// tests that only care whether apiGIDReclaimer falls through to it.
+type fakeGIDReclaimer struct {
+	called bool
+}
+
+func (f *fakeGIDReclaimer) Reclaim(classname string, gidtable *allocator.MinMaxAllocator) error {
+	f.called = true
+	return nil
+}
+
+func newTestPV(name, class string, annotations map[string]string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+		Spec:       v1.PersistentVolumeSpec{StorageClassName: class},
+	}
+}
+
+func TestAPIGIDReclaimerPrefersUpstreamAnnotation(t *testing.T) {
+	const class = "test-class"
+
+	client := fake.NewSimpleClientset(newTestPV("pv-1", class, map[string]string{
+		gidAnnotation:              "2000",
+		pvProvisionerGIDAnnotation: "9999",
+	}))
+
+	gidtable, err := allocator.NewMinMaxAllocator(2000, 2010)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newAPIGIDReclaimer(client, nil)
+	if err := r.Reclaim(class, gidtable); err != nil {
+		t.Fatalf("Reclaim returned error: %v", err)
+	}
+
+	if _, err := gidtable.Allocate(2000); err != allocator.ErrConflict {
+		t.Errorf("expected gid from %s to be allocated, got err=%v", gidAnnotation, err)
+	}
+	if _, err := gidtable.Allocate(9999); err == allocator.ErrConflict {
+		t.Errorf("expected %s to be ignored when %s is present", pvProvisionerGIDAnnotation, gidAnnotation)
+	}
+}
+
+func TestAPIGIDReclaimerFallsBackToProvisionerAnnotation(t *testing.T) {
+	const class = "test-class"
+
+	client := fake.NewSimpleClientset(newTestPV("pv-1", class, map[string]string{
+		pvProvisionerGIDAnnotation: "2001",
+	}))
+
+	gidtable, err := allocator.NewMinMaxAllocator(2000, 2010)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newAPIGIDReclaimer(client, nil)
+	if err := r.Reclaim(class, gidtable); err != nil {
+		t.Fatalf("Reclaim returned error: %v", err)
+	}
+
+	if _, err := gidtable.Allocate(2001); err != allocator.ErrConflict {
+		t.Errorf("expected gid from %s to be allocated, got err=%v", pvProvisionerGIDAnnotation, err)
+	}
+}
+
+func TestAPIGIDReclaimerFallsThroughOnMissingAnnotation(t *testing.T) {
+	const class = "test-class"
+
+	client := fake.NewSimpleClientset(
+		newTestPV("pv-1", class, map[string]string{gidAnnotation: "2000"}),
+		newTestPV("pv-2", class, nil),
+	)
+
+	gidtable, err := allocator.NewMinMaxAllocator(2000, 2010)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fallback := &fakeGIDReclaimer{}
+	r := newAPIGIDReclaimer(client, fallback)
+	if err := r.Reclaim(class, gidtable); err != nil {
+		t.Fatalf("Reclaim returned error: %v", err)
+	}
+
+	if !fallback.called {
+		t.Error("expected the fallback reclaimer to be called since pv-2 has no gid annotation")
+	}
+}
+
+func TestAPIGIDReclaimerNoFallbackWhenAllAnnotated(t *testing.T) {
+	const class = "test-class"
+
+	client := fake.NewSimpleClientset(newTestPV("pv-1", class, map[string]string{gidAnnotation: "2000"}))
+
+	gidtable, err := allocator.NewMinMaxAllocator(2000, 2010)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fallback := &fakeGIDReclaimer{}
+	r := newAPIGIDReclaimer(client, fallback)
+	if err := r.Reclaim(class, gidtable); err != nil {
+		t.Fatalf("Reclaim returned error: %v", err)
+	}
+
+	if fallback.called {
+		t.Error("expected the fallback reclaimer not to be called when every PV has a gid annotation")
+	}
+}
+
+func TestAPIGIDReclaimerIgnoresOtherStorageClasses(t *testing.T) {
+	const class = "test-class"
+
+	client := fake.NewSimpleClientset(newTestPV("pv-1", "other-class", map[string]string{gidAnnotation: "2000"}))
+
+	gidtable, err := allocator.NewMinMaxAllocator(2000, 2010)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newAPIGIDReclaimer(client, nil)
+	if err := r.Reclaim(class, gidtable); err != nil {
+		t.Fatalf("Reclaim returned error: %v", err)
+	}
+
+	if _, err := gidtable.Allocate(2000); err == allocator.ErrConflict {
+		t.Error("expected gid from a PV of a different storage class not to be reclaimed")
+	}
+}
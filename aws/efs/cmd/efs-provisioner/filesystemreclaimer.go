@@ -2,12 +2,17 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"runtime"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/kubernetes-sigs/sig-storage-lib-external-provisioner/allocator"
@@ -16,6 +21,14 @@ import (
 	"k8s.io/kubernetes/pkg/apis/core/v1/helper"
 )
 
+var reclaimWorkers = flag.Int("reclaim-workers", runtime.NumCPU()*2, "number of directories to process concurrently when rebuilding the gid table on startup")
+
+// reclaimLogUsage opts into walking and logging each volume's on-disk usage during Reclaim, at
+// the cost of turning every startup scan into O(file count) disk I/O per volume. It defaults
+// to off; per-volume usage is available on demand without that cost via the metrics registry
+// (see metrics.go) for everything except this one-time startup log line.
+var reclaimLogUsage = flag.Bool("reclaim-log-usage", false, "log each volume's on-disk usage while rebuilding the gid table on startup; walks every directory's contents, so it adds real I/O to every Reclaim")
+
 // compile time check to make sure fileSystemReclaimer implements the GIDReclaimer interface
 var _ gidreclaimer.GIDReclaimer = &fileSystemReclaimer{}
 
@@ -27,9 +40,18 @@ type fileSystemReclaimer struct {
 	BasePath string
 }
 
-// Reclaim looks at every top level directory in the basepath and adds its gid to the given gidTable
+// reclaimCandidate is a directory that parsed out to a GID needing (re)allocation.
+type reclaimCandidate struct {
+	dir string
+	gid int
+}
+
+// Reclaim looks at every top level directory in the basepath and adds its gid to the given
+// gidTable. Directories are read and parsed concurrently by a pool of --reclaim-workers
+// goroutines; allocator.MinMaxAllocator.Allocate is not safe for concurrent use, so the actual
+// allocations are serialized through a single consumer goroutine fed by a channel.
 func (f *fileSystemReclaimer) Reclaim(classname string, gidtable *allocator.MinMaxAllocator) error {
-	glog.Infof("adding gids for any existing directories under %s to the gid table", f.BasePath)
+	start := time.Now()
 
 	entries, err := ioutil.ReadDir(f.BasePath)
 	if err != nil {
@@ -37,51 +59,117 @@ func (f *fileSystemReclaimer) Reclaim(classname string, gidtable *allocator.MinM
 		return err
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
+	workers := *reclaimWorkers
+	if workers < 1 {
+		workers = 1
+	}
 
-		mddir := path.Join(f.BasePath, entry.Name())
+	var scanned, metadataErrors, conflicts int64
 
-		md, err := readVolumeMetadata(mddir)
-		if err != nil {
-			glog.Warningf("failed to read volume metadata for %s: %v", mddir, err)
-			continue
-		}
+	entryCh := make(chan os.FileInfo, len(entries))
+	candidateCh := make(chan reclaimCandidate, workers)
 
-		// if no metadata then it must have been created by another storage class that doesn't have reuseVolumes set since those don't write metadata
-		if md == nil {
-			continue
-		}
+	var producers sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		producers.Add(1)
+		go func() {
+			defer producers.Done()
+			for entry := range entryCh {
+				f.scanEntry(classname, entry, candidateCh, &scanned, &metadataErrors)
+			}
+		}()
+	}
 
-		// skip volumes for other storage classes
-		if md.StorageClassName != classname {
-			continue
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		for candidate := range candidateCh {
+			_, err := gidtable.Allocate(candidate.gid)
+			if err == allocator.ErrConflict {
+				atomic.AddInt64(&conflicts, 1)
+				glog.V(4).Infof("gid %d found in %s was already allocated for storage class %s", candidate.gid, candidate.dir, classname)
+			} else if err != nil {
+				atomic.AddInt64(&metadataErrors, 1)
+				glog.Errorf("failed to store gid %d found in metadata for %s: %v", candidate.gid, candidate.dir, err)
+			}
 		}
+	}()
 
-		// no GID was previously allocated
-		if md.GID == "" {
-			continue
-		}
+	for _, entry := range entries {
+		entryCh <- entry
+	}
+	close(entryCh)
 
-		gid, err := strconv.Atoi(md.GID)
-		if err != nil {
-			glog.Errorf("invalid GID value '%s' in metadata for %s", md.GID, mddir)
-			continue
-		}
+	producers.Wait()
+	close(candidateCh)
+	<-consumerDone
+
+	reclaimDurationSeconds.WithLabelValues(classname).Observe(time.Since(start).Seconds())
+	reclaimDirsScanned.WithLabelValues(classname).Add(float64(scanned))
+	reclaimMetadataErrors.WithLabelValues(classname).Add(float64(metadataErrors))
+	reclaimGIDConflicts.WithLabelValues(classname).Add(float64(conflicts))
+
+	glog.Infof("reclaimed gids for storage class %s under %s in %s: %d directories scanned, %d metadata errors, %d gid conflicts",
+		classname, f.BasePath, time.Since(start), scanned, metadataErrors, conflicts)
+
+	return nil
+}
+
+// scanEntry reads and parses a single top level directory, sending a reclaimCandidate to
+// candidateCh if it yields a GID that needs allocating. It never touches gidtable directly so
+// it can safely run on any of the Reclaim worker goroutines.
+func (f *fileSystemReclaimer) scanEntry(classname string, entry os.FileInfo, candidateCh chan<- reclaimCandidate, scanned, metadataErrors *int64) {
+	if !entry.IsDir() {
+		return
+	}
+
+	// .snapshots holds point-in-time copies, not provisioned volumes; skip it so it's never
+	// mistaken for one.
+	if entry.Name() == snapshotsDirName {
+		return
+	}
 
-		_, err = gidtable.Allocate(gid)
-		if err == allocator.ErrConflict {
-			glog.Infof("gid %d found in %s was already allocated for storageclass %s", gid, mddir, classname)
-			continue
-		} else if err != nil {
-			glog.Errorf("failed to store GID %d found in metadata for %s: %v", gid, mddir, err)
-			continue
+	mddir := path.Join(f.BasePath, entry.Name())
+	atomic.AddInt64(scanned, 1)
+
+	if *reclaimLogUsage {
+		if used, err := du(mddir); err != nil {
+			glog.Warningf("failed to compute usage for %s: %v", mddir, err)
+		} else {
+			glog.Infof("%s is using %d bytes", mddir, used)
 		}
 	}
 
-	return nil
+	md, err := readVolumeMetadata(mddir)
+	if err != nil {
+		glog.V(4).Infof("failed to read volume metadata for %s: %v", mddir, err)
+		atomic.AddInt64(metadataErrors, 1)
+		return
+	}
+
+	// if no metadata then it must have been created by another storage class that doesn't have reuseVolumes set since those don't write metadata
+	if md == nil {
+		return
+	}
+
+	// skip volumes for other storage classes
+	if md.StorageClassName != classname {
+		return
+	}
+
+	// no GID was previously allocated
+	if md.GID == "" {
+		return
+	}
+
+	gid, err := strconv.Atoi(md.GID)
+	if err != nil {
+		glog.V(4).Infof("invalid GID value '%s' in metadata for %s", md.GID, mddir)
+		atomic.AddInt64(metadataErrors, 1)
+		return
+	}
+
+	candidateCh <- reclaimCandidate{dir: mddir, gid: gid}
 }
 
 // validatePreexistingVolume determines if the preexisting directory originally came from the new PVC that is being deployed
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubernetes-sigs/sig-storage-lib-external-provisioner/allocator"
+)
+
+// TestReclaimConcurrent seeds many volumes with distinct GIDs and reclaims them with a worker
+// pool larger than one, so `go test -race` catches any data race between the producer
+// goroutines and the single allocation-consuming goroutine in Reclaim.
+func TestReclaimConcurrent(t *testing.T) {
+	base, err := ioutil.TempDir("", "reclaim-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	const class = "test-class"
+	const numVolumes = 50
+	const minGID = 2000
+
+	for i := 0; i < numVolumes; i++ {
+		dir := filepath.Join(base, fmt.Sprintf("vol-%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		md := &volumeMetadata{
+			StorageClassName: class,
+			PVCName:          fmt.Sprintf("pvc-%d", i),
+			PVCNamespace:     "default",
+			GID:              fmt.Sprintf("%d", minGID+i),
+		}
+		if err := writeVolumeMetadata(dir, md); err != nil {
+			t.Fatalf("failed to seed metadata for %s: %v", dir, err)
+		}
+	}
+
+	workers := 8
+	oldWorkers := reclaimWorkers
+	reclaimWorkers = &workers
+	defer func() { reclaimWorkers = oldWorkers }()
+
+	gidtable, err := allocator.NewMinMaxAllocator(minGID, minGID+numVolumes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newFileSystemReclaimer(base)
+	if err := r.Reclaim(class, gidtable); err != nil {
+		t.Fatalf("Reclaim returned error: %v", err)
+	}
+
+	for i := 0; i < numVolumes; i++ {
+		// every seeded gid should already be allocated, so trying to allocate it again must
+		// come back as a conflict
+		if _, err := gidtable.Allocate(minGID + i); err != allocator.ErrConflict {
+			t.Errorf("expected gid %d to already be allocated by Reclaim, got err=%v", minGID+i, err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+)
+
+// snapshotsDirName is the top level directory under BasePath that holds all snapshots. The
+// reclaimer treats it as infrastructure, never as a provisioned volume.
+const snapshotsDirName = ".snapshots"
+
+const snapshotMetadataFileName = "snapshot-metadata.json"
+
+// fsSnapshotter implements snapshotter by storing snapshots under
+// <BasePath>/.snapshots/<pvc-uid>/<snap-name>/, hardlinking the source directory's files into
+// place where possible (cp -al semantics, cheap on the same filesystem) and falling back to a
+// full copy when hardlinking fails, e.g. because the destination is on a different device.
+// A hardlinked snapshot shares its files' data with the source, so a source file modified in
+// place after the snapshot (rather than replaced) changes the snapshot's copy too.
+type fsSnapshotter struct {
+	BasePath string
+}
+
+func newFSSnapshotter(basePath string) *fsSnapshotter {
+	return &fsSnapshotter{BasePath: basePath}
+}
+
+func (f *fsSnapshotter) dir(pvcUID, snapName string) string {
+	return filepath.Join(f.BasePath, snapshotsDirName, pvcUID, snapName)
+}
+
+// Snapshot implements snapshotter.
+func (f *fsSnapshotter) Snapshot(pvcUID, snapName, sourcePath string, meta snapshotMetadata) error {
+	dest := f.dir(pvcUID, snapName)
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory for %s/%s: %v", pvcUID, snapName, err)
+	}
+
+	if err := hardlinkTree(sourcePath, dest); err != nil {
+		glog.Warningf("hardlink snapshot of %s failed (%v), falling back to a full copy", sourcePath, err)
+		os.RemoveAll(dest)
+		if err := copyTree(sourcePath, dest); err != nil {
+			return fmt.Errorf("failed to snapshot %s to %s: %v", sourcePath, dest, err)
+		}
+	}
+
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot metadata for %s/%s: %v", pvcUID, snapName, err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dest, snapshotMetadataFileName), payload, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot metadata for %s/%s: %v", pvcUID, snapName, err)
+	}
+
+	return nil
+}
+
+// Restore implements snapshotter.
+func (f *fsSnapshotter) Restore(pvcUID, snapName, destPath string) (*snapshotMetadata, error) {
+	src := f.dir(pvcUID, snapName)
+
+	meta, err := readSnapshotMetadata(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := copyTree(src, destPath); err != nil {
+		return nil, fmt.Errorf("failed to restore snapshot %s/%s to %s: %v", pvcUID, snapName, destPath, err)
+	}
+
+	// the sidecar metadata file belongs to the snapshot, not the volume restored from it
+	os.Remove(filepath.Join(destPath, snapshotMetadataFileName))
+
+	return meta, nil
+}
+
+// Delete implements snapshotter.
+func (f *fsSnapshotter) Delete(pvcUID, snapName string) error {
+	return os.RemoveAll(f.dir(pvcUID, snapName))
+}
+
+// Locate implements snapshotter by searching every pvc-uid directory for one containing
+// snapName.
+func (f *fsSnapshotter) Locate(snapName string) (string, error) {
+	root := filepath.Join(f.BasePath, snapshotsDirName)
+
+	pvcDirs, err := ioutil.ReadDir(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to list %s: %v", root, err)
+	}
+
+	for _, pvcDir := range pvcDirs {
+		if !pvcDir.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, pvcDir.Name(), snapName)); err == nil {
+			return pvcDir.Name(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no snapshot named %s found under %s", snapName, root)
+}
+
+func readSnapshotMetadata(dir string) (*snapshotMetadata, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, snapshotMetadataFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot metadata in %s: %v", dir, err)
+	}
+
+	meta := &snapshotMetadata{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot metadata in %s: %v", dir, err)
+	}
+
+	return meta, nil
+}
+
+// hardlinkTree recreates the directory structure of src under dst, hardlinking every regular
+// file instead of copying its contents. It fails outright if src and dst aren't on the same
+// filesystem, since hardlinks can't cross devices; the caller is responsible for cleaning up
+// any partial tree left behind before falling back to copyTree.
+func hardlinkTree(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode())
+		}
+
+		return os.Link(p, target)
+	})
+}
+
+// copyTree recreates the directory structure of src under dst, copying file contents. Used
+// when hardlinkTree can't be used because src and dst are on different filesystems.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode())
+		}
+
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
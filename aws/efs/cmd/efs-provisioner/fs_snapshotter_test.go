@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFSSnapshotterRoundTrip(t *testing.T) {
+	base, err := ioutil.TempDir("", "snapshotter-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	source := filepath.Join(base, "source")
+	if err := os.MkdirAll(filepath.Join(source, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(source, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(source, "sub", "nested.txt"), []byte("world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshotter := newFSSnapshotter(base)
+
+	const pvcUID = "test-pvc-uid"
+	const snapName = "test-snap"
+
+	meta := snapshotMetadata{SourcePVCName: "my-pvc", GID: "2000", Timestamp: "2026-07-27T00:00:00Z"}
+	if err := snapshotter.Snapshot(pvcUID, snapName, source, meta); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	foundUID, err := snapshotter.Locate(snapName)
+	if err != nil {
+		t.Fatalf("Locate failed: %v", err)
+	}
+	if foundUID != pvcUID {
+		t.Errorf("expected Locate to find pvcUID %s, got %s", pvcUID, foundUID)
+	}
+
+	dest := filepath.Join(base, "restored")
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	restoredMeta, err := snapshotter.Restore(pvcUID, snapName, dest)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restoredMeta.SourcePVCName != meta.SourcePVCName || restoredMeta.GID != meta.GID {
+		t.Errorf("restored metadata %+v does not match original %+v", restoredMeta, meta)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dest, "file.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Errorf("expected restored file.txt to contain 'hello', got %q (err=%v)", data, err)
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dest, "sub", "nested.txt"))
+	if err != nil || string(data) != "world" {
+		t.Errorf("expected restored sub/nested.txt to contain 'world', got %q (err=%v)", data, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, snapshotMetadataFileName)); !os.IsNotExist(err) {
+		t.Errorf("expected the sidecar snapshot metadata file not to be copied into the restored volume")
+	}
+}
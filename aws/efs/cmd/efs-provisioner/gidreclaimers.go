@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/kubernetes-sigs/sig-storage-lib-external-provisioner/gidreclaimer"
+	"k8s.io/client-go/kubernetes"
+)
+
+var gidReclaimerKind = flag.String("gid-reclaimer", "both", "which GID reclaimer to use on startup to rebuild the gid table: fs, api, or both")
+
+// gidReclaimerBuilder constructs a gidreclaimer.GIDReclaimer given the provisioner's BasePath
+// and Kubernetes client. Downstream forks can register their own, e.g. one backed by a
+// central KV store, by adding to gidReclaimerBuilders in an init().
+type gidReclaimerBuilder func(basePath string, client kubernetes.Interface) gidreclaimer.GIDReclaimer
+
+// gidReclaimerBuilders is keyed by the value accepted for --gid-reclaimer.
+var gidReclaimerBuilders = map[string]gidReclaimerBuilder{
+	"fs": func(basePath string, client kubernetes.Interface) gidreclaimer.GIDReclaimer {
+		return newFileSystemReclaimer(basePath)
+	},
+	"api": func(basePath string, client kubernetes.Interface) gidreclaimer.GIDReclaimer {
+		return newAPIGIDReclaimer(client, nil)
+	},
+	"both": func(basePath string, client kubernetes.Interface) gidreclaimer.GIDReclaimer {
+		return newAPIGIDReclaimer(client, newFileSystemReclaimer(basePath))
+	},
+}
+
+// newGIDReclaimer builds the gidreclaimer.GIDReclaimer registered under kind, which must be a
+// key in gidReclaimerBuilders ("fs", "api" or "both" out of the box).
+func newGIDReclaimer(kind, basePath string, client kubernetes.Interface) (gidreclaimer.GIDReclaimer, error) {
+	builder, ok := gidReclaimerBuilders[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown --gid-reclaimer %q", kind)
+	}
+
+	return builder(basePath, client), nil
+}
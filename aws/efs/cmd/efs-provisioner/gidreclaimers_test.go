@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGIDReclaimerDefaultsToBoth(t *testing.T) {
+	if got := *gidReclaimerKind; got != "both" {
+		t.Errorf("expected --gid-reclaimer to default to %q so the api-backed reclaimer is used out of the box, got %q", "both", got)
+	}
+}
+
+func TestNewGIDReclaimerBothFallsThroughToFS(t *testing.T) {
+	reclaimer, err := newGIDReclaimer("both", "/tmp", fake.NewSimpleClientset())
+	if err != nil {
+		t.Fatalf("newGIDReclaimer returned error: %v", err)
+	}
+
+	api, ok := reclaimer.(*apiGIDReclaimer)
+	if !ok {
+		t.Fatalf("expected \"both\" to build an *apiGIDReclaimer, got %T", reclaimer)
+	}
+	if _, ok := api.fallback.(*fileSystemReclaimer); !ok {
+		t.Errorf("expected \"both\"'s apiGIDReclaimer to fall back to a *fileSystemReclaimer, got %T", api.fallback)
+	}
+}
+
+func TestNewGIDReclaimerUnknownKind(t *testing.T) {
+	if _, err := newGIDReclaimer("bogus", "/tmp", fake.NewSimpleClientset()); err == nil {
+		t.Error("expected an unknown --gid-reclaimer kind to return an error")
+	}
+}
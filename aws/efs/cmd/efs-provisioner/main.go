@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/golang/glog"
+	snapshotclientset "github.com/kubernetes-csi/external-snapshotter/pkg/client/clientset/versioned"
+	"github.com/kubernetes-sigs/sig-storage-lib-external-provisioner/controller"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	master            = flag.String("master", "", "master URL; only required if out-of-cluster")
+	kubeconfig        = flag.String("kubeconfig", "", "absolute path to a kubeconfig file; only required if out-of-cluster")
+	provisionerName   = flag.String("provisioner", "example.com/aws-efs", "name of the provisioner, must be unique and match the provisioner field of the StorageClasses it serves")
+	basePath          = flag.String("path", "/persistentvolumes", "path under which the provisioner creates and removes volume directories")
+	nfsServer         = flag.String("server", "", "address of the NFS server backing --path, used as the server in the PVs this provisioner creates")
+	metricsAddr       = flag.String("metrics-addr", ":8080", "address to serve Prometheus volume metrics on; empty disables the metrics server")
+	metricsPollEvery  = flag.Duration("metrics-poll-interval", defaultMetricsTTL, "how often to refresh Prometheus volume metrics for every provisioned directory")
+	snapshotClassName = flag.String("snapshot-class", "", "VolumeSnapshotClass this provisioner's snapshot controller watches for; empty disables snapshot support")
+	snapshotResync    = flag.Duration("snapshot-resync-period", 10*time.Minute, "resync period for the VolumeSnapshot informer")
+)
+
+func main() {
+	flag.Parse()
+
+	config, err := clientcmd.BuildConfigFromFlags(*master, *kubeconfig)
+	if err != nil {
+		glog.Fatalf("failed to build client config: %v", err)
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("failed to create kubernetes client: %v", err)
+	}
+
+	stopCh := wait.NeverStop
+
+	provisioner := newEFSProvisioner(client, *basePath, *nfsServer, *snapshotClassName != "", stopCh)
+
+	if *metricsAddr != "" {
+		go ServeMetrics(*metricsAddr)
+		go pollVolumeMetrics(*basePath, provisioner.metrics, *metricsPollEvery, stopCh)
+	}
+
+	if *snapshotClassName != "" {
+		snapshotClient, err := snapshotclientset.NewForConfig(config)
+		if err != nil {
+			glog.Fatalf("failed to create snapshot client: %v", err)
+		}
+
+		snapController := newSnapshotController(*provisionerName, *snapshotClassName, *basePath, snapshotClient, provisioner.snapshotter, *snapshotResync)
+		go snapController.Run(stopCh)
+	}
+
+	pc := controller.NewProvisionController(client, *provisionerName, provisioner, "efs-provisioner")
+	pc.Run(stopCh)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metadataFileName is the stable name readers look up; writeVolumeMetadata makes it a symlink
+// through dataDirName so a reader never observes a partially written payload. Records written
+// before the atomic writer existed are plain files under this name until they are rewritten.
+const metadataFileName = "volume-metadata.json"
+
+// dataDirName is a symlink to the current timestamped payload file, swapped atomically by
+// writeVolumeMetadata on every write.
+const dataDirName = "..data"
+
+// currentSchemaVersion is the volumeMetadata schema this binary writes. Records on disk from
+// before SchemaVersion existed are treated as schema version 1.
+const currentSchemaVersion = 1
+
+// volumeMetadata is the sidecar record written alongside a provisioned directory so the
+// provisioner can recognize the directory again later (e.g. to rebuild the GID table on
+// restart, or to validate a Retain-reclaimed directory against a new PVC).
+type volumeMetadata struct {
+	SchemaVersion    int    `json:"schemaVersion"`
+	StorageClassName string `json:"storageClassName"`
+	PVCName          string `json:"pvcName"`
+	PVCNamespace     string `json:"pvcNamespace"`
+	GID              string `json:"gid"`
+}
+
+// GidAsUInt parses the GID field, which is stored as a string for backwards compatibility
+// with records written before GID validation existed.
+func (m *volumeMetadata) GidAsUInt() (uint32, error) {
+	gid, err := strconv.ParseUint(m.GID, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(gid), nil
+}
+
+// readVolumeMetadata reads the volume metadata for the directory at dir. It returns a nil
+// record (and nil error) if the directory has no metadata file, which happens for volumes
+// created by storage classes that don't have reuseVolumes set. Records older than
+// currentSchemaVersion are migrated and rewritten atomically before being returned.
+func readVolumeMetadata(dir string) (*volumeMetadata, error) {
+	data, err := ioutil.ReadFile(path.Join(dir, metadataFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	md := &volumeMetadata{}
+	if err := json.Unmarshal(data, md); err != nil {
+		return nil, fmt.Errorf("failed to parse volume metadata in %s: %v", dir, err)
+	}
+
+	if md.SchemaVersion == 0 {
+		// predates the SchemaVersion field entirely
+		md.SchemaVersion = 1
+	}
+
+	if md.SchemaVersion < currentSchemaVersion {
+		if err := migrate(md); err != nil {
+			return nil, fmt.Errorf("failed to migrate volume metadata in %s: %v", dir, err)
+		}
+		if err := writeVolumeMetadata(dir, md); err != nil {
+			return nil, fmt.Errorf("failed to persist migrated volume metadata in %s: %v", dir, err)
+		}
+	}
+
+	return md, nil
+}
+
+// writeVolumeMetadata atomically (re)writes md to dir: the payload is written to a
+// ..data_tmp_<ts> sibling file and fsync'd, then renamed into a ..data_<ts> payload file and
+// the parent directory fsync'd, before the ..data and metadataFileName symlinks are swapped to
+// point at it. A reader can never observe a partially written payload.
+func writeVolumeMetadata(dir string, md *volumeMetadata) error {
+	md.SchemaVersion = currentSchemaVersion
+
+	payload, err := json.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("failed to marshal volume metadata: %v", err)
+	}
+
+	ts := time.Now().UnixNano()
+	tmpPath := path.Join(dir, fmt.Sprintf("..data_tmp_%d", ts))
+	finalName := fmt.Sprintf("..data_%d", ts)
+
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", tmpPath, err)
+	}
+
+	if _, err := f.Write(payload); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %v", tmpPath, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to fsync %s: %v", tmpPath, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close %s: %v", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path.Join(dir, finalName)); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename %s into place: %v", tmpPath, err)
+	}
+
+	if err := fsyncDir(dir); err != nil {
+		return fmt.Errorf("failed to fsync %s after writing volume metadata: %v", dir, err)
+	}
+
+	if err := swapSymlink(dir, dataDirName, finalName); err != nil {
+		return err
+	}
+	if err := swapSymlink(dir, metadataFileName, dataDirName); err != nil {
+		return err
+	}
+
+	// the renames above that swing the symlinks into place are themselves directory entry
+	// changes and need their own fsync, or an unclean shutdown could lose the swap even
+	// though the payload file it points to survived.
+	if err := fsyncDir(dir); err != nil {
+		return fmt.Errorf("failed to fsync %s after swapping volume metadata symlinks: %v", dir, err)
+	}
+
+	removeStalePayloads(dir, finalName)
+
+	return nil
+}
+
+// fsyncDir opens dir and calls fsync on it, which is necessary after a rename(2) for the
+// rename itself to be durable.
+func fsyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// swapSymlink atomically repoints the symlink dir/linkName at target by creating a temporary
+// symlink and renaming it over the old one, so readers never see linkName missing or
+// dangling mid-write.
+func swapSymlink(dir, linkName, target string) error {
+	tmpLink := path.Join(dir, fmt.Sprintf("..%s_tmp_%d", linkName, time.Now().UnixNano()))
+
+	if err := os.Symlink(target, tmpLink); err != nil {
+		return fmt.Errorf("failed to create symlink %s: %v", tmpLink, err)
+	}
+	if err := os.Rename(tmpLink, path.Join(dir, linkName)); err != nil {
+		os.Remove(tmpLink)
+		return fmt.Errorf("failed to swap symlink %s: %v", linkName, err)
+	}
+
+	return nil
+}
+
+// removeStalePayloads best-effort removes ..data_<ts> payload files other than keep, left
+// behind by previous writes. Failures are not fatal since they just leave a harmless orphan
+// file for the next write to clean up.
+func removeStalePayloads(dir, keep string) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == keep || !strings.HasPrefix(name, "..data_") {
+			continue
+		}
+		os.Remove(path.Join(dir, name))
+	}
+}
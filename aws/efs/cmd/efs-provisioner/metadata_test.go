@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAndReadVolumeMetadataRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metadata-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	md := &volumeMetadata{
+		StorageClassName: "my-class",
+		PVCName:          "my-pvc",
+		PVCNamespace:     "default",
+		GID:              "2000",
+	}
+
+	if err := writeVolumeMetadata(dir, md); err != nil {
+		t.Fatalf("writeVolumeMetadata failed: %v", err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(dir, metadataFileName)); err != nil {
+		t.Fatalf("expected %s to exist: %v", metadataFileName, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, dataDirName)); err != nil {
+		t.Fatalf("expected %s to exist: %v", dataDirName, err)
+	}
+
+	read, err := readVolumeMetadata(dir)
+	if err != nil {
+		t.Fatalf("readVolumeMetadata failed: %v", err)
+	}
+	if read == nil {
+		t.Fatal("expected readVolumeMetadata to return a record, got nil")
+	}
+	if read.SchemaVersion != currentSchemaVersion {
+		t.Errorf("expected SchemaVersion %d, got %d", currentSchemaVersion, read.SchemaVersion)
+	}
+	if read.PVCName != md.PVCName || read.GID != md.GID {
+		t.Errorf("round-tripped metadata %+v does not match original %+v", read, md)
+	}
+}
+
+func TestWriteVolumeMetadataOverwritesCleanly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metadata-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := &volumeMetadata{StorageClassName: "class-a", PVCName: "pvc-a", PVCNamespace: "ns", GID: "2000"}
+	if err := writeVolumeMetadata(dir, first); err != nil {
+		t.Fatalf("first write failed: %v", err)
+	}
+
+	second := &volumeMetadata{StorageClassName: "class-a", PVCName: "pvc-a", PVCNamespace: "ns", GID: "2001"}
+	if err := writeVolumeMetadata(dir, second); err != nil {
+		t.Fatalf("second write failed: %v", err)
+	}
+
+	read, err := readVolumeMetadata(dir)
+	if err != nil {
+		t.Fatalf("readVolumeMetadata failed: %v", err)
+	}
+	if read.GID != "2001" {
+		t.Errorf("expected the second write's gid 2001 to win, got %s", read.GID)
+	}
+
+	// the stale payload from the first write should have been cleaned up
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payloadCount int
+	for _, entry := range entries {
+		if len(entry.Name()) > len("..data_") && entry.Name()[:len("..data_")] == "..data_" {
+			payloadCount++
+		}
+	}
+	if payloadCount != 1 {
+		t.Errorf("expected exactly one payload file to remain after overwriting, found %d", payloadCount)
+	}
+}
+
+func TestReadVolumeMetadataMigratesLegacyRecordWithNoSchemaVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metadata-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// simulate a pre-SchemaVersion record written directly, the way on-disk directories from
+	// before this field existed would look
+	legacy := []byte(`{"storageClassName":"my-class","pvcName":"my-pvc","pvcNamespace":"default","gid":"2000"}`)
+	if err := ioutil.WriteFile(filepath.Join(dir, metadataFileName), legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := readVolumeMetadata(dir)
+	if err != nil {
+		t.Fatalf("readVolumeMetadata failed on a legacy record: %v", err)
+	}
+	if md.SchemaVersion != currentSchemaVersion {
+		t.Errorf("expected a legacy record to be normalized to schema version %d, got %d", currentSchemaVersion, md.SchemaVersion)
+	}
+	if md.GID != "2000" {
+		t.Errorf("expected the legacy gid to survive migration, got %s", md.GID)
+	}
+}
+
+func TestReadVolumeMetadataNoFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metadata-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	md, err := readVolumeMetadata(dir)
+	if err != nil {
+		t.Fatalf("expected no error for a directory with no metadata, got %v", err)
+	}
+	if md != nil {
+		t.Errorf("expected a nil record for a directory with no metadata, got %+v", md)
+	}
+}
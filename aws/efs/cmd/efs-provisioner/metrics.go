@@ -0,0 +1,198 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// defaultMetricsTTL bounds how long a volume's metrics are cached between walks/statfs calls.
+const defaultMetricsTTL = 30 * time.Second
+
+// VolumeMetrics reports capacity, usage and inode information for a single provisioned
+// directory.
+type VolumeMetrics struct {
+	Capacity   *resource.Quantity
+	Used       *resource.Quantity
+	Available  *resource.Quantity
+	Inodes     *resource.Quantity
+	InodesFree *resource.Quantity
+	InodesUsed *resource.Quantity
+}
+
+// MetricsProvider gives access to the VolumeMetrics for whatever path it was built with.
+type MetricsProvider interface {
+	GetMetrics() (*VolumeMetrics, error)
+}
+
+var (
+	volumeCapacityBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "efs_provisioner",
+		Name:      "volume_capacity_bytes",
+		Help:      "Capacity in bytes of the filesystem backing a provisioned volume",
+	}, []string{"volume_path"})
+	volumeUsedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "efs_provisioner",
+		Name:      "volume_used_bytes",
+		Help:      "Bytes used under a provisioned volume",
+	}, []string{"volume_path"})
+	volumeAvailableBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "efs_provisioner",
+		Name:      "volume_available_bytes",
+		Help:      "Bytes available to a provisioned volume's filesystem",
+	}, []string{"volume_path"})
+	volumeInodesFree = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "efs_provisioner",
+		Name:      "volume_inodes_free",
+		Help:      "Free inodes on the filesystem backing a provisioned volume",
+	}, []string{"volume_path"})
+)
+
+func init() {
+	prometheus.MustRegister(volumeCapacityBytes, volumeUsedBytes, volumeAvailableBytes, volumeInodesFree)
+}
+
+// volumeMetricsRegistry keeps one cached MetricsProvider per provisioned volume path and
+// refreshes the Prometheus gauges above whenever a volume is scraped or polled.
+type volumeMetricsRegistry struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	providers map[string]MetricsProvider
+}
+
+func newVolumeMetricsRegistry(ttl time.Duration) *volumeMetricsRegistry {
+	return &volumeMetricsRegistry{
+		ttl:       ttl,
+		providers: make(map[string]MetricsProvider),
+	}
+}
+
+// Poll fetches (and caches) the metrics for volumePath and publishes them to Prometheus.
+func (r *volumeMetricsRegistry) Poll(volumePath string) (*VolumeMetrics, error) {
+	provider := r.providerFor(volumePath)
+
+	metrics, err := provider.GetMetrics()
+	if err != nil {
+		return nil, err
+	}
+
+	if metrics.Capacity != nil {
+		volumeCapacityBytes.WithLabelValues(volumePath).Set(float64(metrics.Capacity.Value()))
+	}
+	if metrics.Used != nil {
+		volumeUsedBytes.WithLabelValues(volumePath).Set(float64(metrics.Used.Value()))
+	}
+	if metrics.Available != nil {
+		volumeAvailableBytes.WithLabelValues(volumePath).Set(float64(metrics.Available.Value()))
+	}
+	if metrics.InodesFree != nil {
+		volumeInodesFree.WithLabelValues(volumePath).Set(float64(metrics.InodesFree.Value()))
+	}
+
+	return metrics, nil
+}
+
+// Remove drops the cached provider and gauge values for a volume path that no longer exists,
+// e.g. after a successful Delete.
+func (r *volumeMetricsRegistry) Remove(volumePath string) {
+	r.mu.Lock()
+	delete(r.providers, volumePath)
+	r.mu.Unlock()
+
+	volumeCapacityBytes.DeleteLabelValues(volumePath)
+	volumeUsedBytes.DeleteLabelValues(volumePath)
+	volumeAvailableBytes.DeleteLabelValues(volumePath)
+	volumeInodesFree.DeleteLabelValues(volumePath)
+}
+
+func (r *volumeMetricsRegistry) providerFor(volumePath string) MetricsProvider {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if provider, ok := r.providers[volumePath]; ok {
+		return provider
+	}
+
+	// statfs gives capacity/available/inodes cheaply; du is the only way to get actual bytes
+	// used by this volume's files when several volumes share one filesystem.
+	provider := newCachedMetricsProvider(&multiMetricsProvider{
+		statfs: newMetricsStatfs(volumePath),
+		du:     newMetricsDu(volumePath),
+	}, r.ttl)
+	r.providers[volumePath] = provider
+	return provider
+}
+
+// multiMetricsProvider combines the cheap statfs-derived capacity/availability/inode figures
+// with the more expensive du-derived used-bytes figure for a single volume path.
+type multiMetricsProvider struct {
+	statfs *metricsStatfs
+	du     *metricsDu
+}
+
+func (m *multiMetricsProvider) GetMetrics() (*VolumeMetrics, error) {
+	metrics, err := m.statfs.GetMetrics()
+	if err != nil {
+		return nil, err
+	}
+
+	du, err := m.du.GetMetrics()
+	if err != nil {
+		glog.Warningf("failed to compute du usage for %v, falling back to statfs used bytes: %v", m.du.path, err)
+		return metrics, nil
+	}
+
+	metrics.Used = du.Used
+	return metrics, nil
+}
+
+// ServeMetrics starts an HTTP server exposing the registered volume metrics at /metrics for
+// Prometheus to scrape.
+func ServeMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	glog.Infof("serving volume metrics on %s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		glog.Errorf("metrics server exited: %v", err)
+	}
+}
+
+// pollVolumeMetrics refreshes the Prometheus gauges for every provisioned directory directly
+// under basePath on a ticker until stopCh is closed, so a scrape always sees current numbers
+// even for volumes whose StorageClass doesn't have enforceQuota set.
+func pollVolumeMetrics(basePath string, registry *volumeMetricsRegistry, interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			entries, err := ioutil.ReadDir(basePath)
+			if err != nil {
+				glog.Errorf("failed to list %s for metrics polling: %v", basePath, err)
+				continue
+			}
+
+			for _, entry := range entries {
+				if !entry.IsDir() || entry.Name() == snapshotsDirName {
+					continue
+				}
+				volumePath := path.Join(basePath, entry.Name())
+				if _, err := registry.Poll(volumePath); err != nil {
+					glog.Warningf("failed to poll metrics for %s: %v", volumePath, err)
+				}
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
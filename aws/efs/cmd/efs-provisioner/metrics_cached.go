@@ -0,0 +1,41 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// cachedMetricsProvider memoizes another MetricsProvider's result for ttl, so repeated
+// Prometheus scrapes of the same volume don't re-walk or re-statfs its path.
+type cachedMetricsProvider struct {
+	provider MetricsProvider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	metrics *VolumeMetrics
+	fetched time.Time
+}
+
+func newCachedMetricsProvider(provider MetricsProvider, ttl time.Duration) *cachedMetricsProvider {
+	return &cachedMetricsProvider{provider: provider, ttl: ttl}
+}
+
+// GetMetrics returns the last fetched metrics if they're still within ttl, otherwise it
+// fetches fresh ones from the wrapped provider.
+func (c *cachedMetricsProvider) GetMetrics() (*VolumeMetrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.metrics != nil && time.Since(c.fetched) < c.ttl {
+		return c.metrics, nil
+	}
+
+	metrics, err := c.provider.GetMetrics()
+	if err != nil {
+		return nil, err
+	}
+
+	c.metrics = metrics
+	c.fetched = time.Now()
+	return c.metrics, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// metricsDu computes a volume's used bytes by walking its directory tree and summing the
+// size of every non-directory entry.
+type metricsDu struct {
+	path string
+}
+
+func newMetricsDu(path string) *metricsDu {
+	return &metricsDu{path: path}
+}
+
+// GetMetrics walks the directory tree rooted at m.path and returns the summed size as Used.
+func (m *metricsDu) GetMetrics() (*VolumeMetrics, error) {
+	used, err := du(m.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VolumeMetrics{Used: resource.NewQuantity(used, resource.BinarySI)}, nil
+}
+
+// du sums the apparent size (file length, not allocated blocks) of every non-directory entry
+// under path, using Lstat (via filepath.Walk) so symlinks are counted by their own size rather
+// than the size of what they point to.
+func du(path string) (int64, error) {
+	var usage int64
+
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			// the entry may have been removed between being listed and being Lstat'ed
+			return nil
+		}
+		if !info.IsDir() {
+			usage += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return usage, nil
+}
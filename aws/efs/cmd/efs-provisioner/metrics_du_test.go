@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeMetricsProvider counts how many times GetMetrics is called, so tests can assert that
+// cachedMetricsProvider actually avoids re-fetching within its TTL.
+type fakeMetricsProvider struct {
+	calls   int
+	metrics *VolumeMetrics
+}
+
+func (f *fakeMetricsProvider) GetMetrics() (*VolumeMetrics, error) {
+	f.calls++
+	return f.metrics, nil
+}
+
+func TestDuSumsFileSizes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "du-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "a"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "b"), make([]byte, 250), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	used, err := du(dir)
+	if err != nil {
+		t.Fatalf("du returned error: %v", err)
+	}
+	if used != 350 {
+		t.Errorf("expected du to sum to 350 bytes, got %d", used)
+	}
+}
+
+func TestCachedMetricsProviderHonorsTTL(t *testing.T) {
+	fake := &fakeMetricsProvider{metrics: &VolumeMetrics{}}
+	cached := newCachedMetricsProvider(fake, time.Hour)
+
+	if _, err := cached.GetMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.GetMetrics(); err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.calls != 1 {
+		t.Errorf("expected the wrapped provider to be called once while within ttl, got %d calls", fake.calls)
+	}
+}
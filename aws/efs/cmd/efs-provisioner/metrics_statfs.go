@@ -0,0 +1,37 @@
+package main
+
+import (
+	"syscall"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// metricsStatfs reports capacity, availability and inode counts for the filesystem backing
+// a path using statfs(2).
+type metricsStatfs struct {
+	path string
+}
+
+func newMetricsStatfs(path string) *metricsStatfs {
+	return &metricsStatfs{path: path}
+}
+
+// GetMetrics statfs's m.path and derives Capacity, Used, Available and inode counts from it.
+func (m *metricsStatfs) GetMetrics() (*VolumeMetrics, error) {
+	var buf syscall.Statfs_t
+	if err := syscall.Statfs(m.path, &buf); err != nil {
+		return nil, err
+	}
+
+	capacity := int64(buf.Blocks) * int64(buf.Bsize)
+	available := int64(buf.Bavail) * int64(buf.Bsize)
+
+	return &VolumeMetrics{
+		Capacity:   resource.NewQuantity(capacity, resource.BinarySI),
+		Used:       resource.NewQuantity(capacity-available, resource.BinarySI),
+		Available:  resource.NewQuantity(available, resource.BinarySI),
+		Inodes:     resource.NewQuantity(int64(buf.Files), resource.BinarySI),
+		InodesFree: resource.NewQuantity(int64(buf.Ffree), resource.BinarySI),
+		InodesUsed: resource.NewQuantity(int64(buf.Files)-int64(buf.Ffree), resource.BinarySI),
+	}, nil
+}
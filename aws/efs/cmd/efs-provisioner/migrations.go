@@ -0,0 +1,27 @@
+package main
+
+import "fmt"
+
+// migrator upgrades a volumeMetadata record by exactly one schema version (v1 -> v2,
+// v2 -> v3, ...), so migrate can chain them regardless of how far behind a record is.
+type migrator func(md *volumeMetadata) error
+
+// migrators is keyed by the version a migrator upgrades *from*. Register a new entry here
+// whenever currentSchemaVersion is bumped in metadata.go, e.g.:
+//
+//	migrators[2] = migrateV2ToV3
+var migrators = map[int]migrator{}
+
+// migrate runs registered migrators in order until md is at currentSchemaVersion.
+func migrate(md *volumeMetadata) error {
+	for md.SchemaVersion < currentSchemaVersion {
+		m, ok := migrators[md.SchemaVersion]
+		if !ok {
+			return fmt.Errorf("no migrator registered to upgrade volume metadata from schema version %d", md.SchemaVersion)
+		}
+		if err := m(md); err != nil {
+			return fmt.Errorf("failed to migrate volume metadata from schema version %d: %v", md.SchemaVersion, err)
+		}
+	}
+	return nil
+}
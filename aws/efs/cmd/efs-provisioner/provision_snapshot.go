@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-sigs/sig-storage-lib-external-provisioner/controller"
+)
+
+// volumeSnapshotDataSourceKind is the dataSource.Kind Provision recognizes as "rehydrate this
+// new volume from an existing snapshot" rather than provisioning an empty directory.
+const volumeSnapshotDataSourceKind = "VolumeSnapshot"
+
+// restoreFromDataSourceIfNeeded rehydrates volumePath from options.PVC's dataSource, if it
+// names one. Provision should call this, with volumePath already created and empty, before
+// handing the new PV back to the caller. It returns (nil, nil) if the PVC has no dataSource.
+func restoreFromDataSourceIfNeeded(options controller.VolumeOptions, volumePath string, snapshotter snapshotter) (*snapshotMetadata, error) {
+	ds := options.PVC.Spec.DataSource
+	if ds == nil {
+		return nil, nil
+	}
+	if ds.Kind != volumeSnapshotDataSourceKind {
+		return nil, fmt.Errorf("unsupported dataSource kind %q, only %s is supported", ds.Kind, volumeSnapshotDataSourceKind)
+	}
+
+	pvcUID, err := snapshotter.Locate(ds.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate snapshot %s: %v", ds.Name, err)
+	}
+
+	return snapshotter.Restore(pvcUID, ds.Name, volumePath)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kubernetes-sigs/sig-storage-lib-external-provisioner/allocator"
+	"github.com/kubernetes-sigs/sig-storage-lib-external-provisioner/controller"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/apis/core/v1/helper"
+)
+
+// StorageClass parameters efsProvisioner understands, and the gid range used when gidMin or
+// gidMax is not set.
+const (
+	gidMinParam       = "gidMin"
+	gidMaxParam       = "gidMax"
+	reuseVolumesParam = "reuseVolumes"
+	enforceQuotaParam = "enforceQuota"
+	defaultGidMin     = 2000
+	defaultGidMax     = 2147483647
+)
+
+// compile time check to make sure efsProvisioner implements the Provisioner interface
+var _ controller.Provisioner = &efsProvisioner{}
+
+// efsProvisioner implements controller.Provisioner against directories under BasePath on a
+// shared EFS mount, handing back an NFS PersistentVolume pointed at Server for each one.
+type efsProvisioner struct {
+	client           kubernetes.Interface
+	recorder         record.EventRecorder
+	basePath         string
+	server           string
+	metrics          *volumeMetricsRegistry
+	snapshotter      snapshotter
+	snapshotsEnabled bool
+	stopCh           <-chan struct{}
+
+	mu        sync.Mutex
+	gidTables map[string]*allocator.MinMaxAllocator
+	quotaStop map[string]chan struct{}
+}
+
+func newEFSProvisioner(client kubernetes.Interface, basePath, server string, snapshotsEnabled bool, stopCh <-chan struct{}) *efsProvisioner {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "efs-provisioner"})
+
+	return &efsProvisioner{
+		client:           client,
+		recorder:         recorder,
+		basePath:         basePath,
+		server:           server,
+		metrics:          newVolumeMetricsRegistry(defaultMetricsTTL),
+		snapshotter:      newFSSnapshotter(basePath),
+		snapshotsEnabled: snapshotsEnabled,
+		stopCh:           stopCh,
+		gidTables:   make(map[string]*allocator.MinMaxAllocator),
+		quotaStop:   make(map[string]chan struct{}),
+	}
+}
+
+// Provision implements controller.Provisioner.
+func (p *efsProvisioner) Provision(options controller.VolumeOptions) (*v1.PersistentVolume, error) {
+	class := helper.GetPersistentVolumeClaimClass(options.PVC)
+
+	gidTable, err := p.gidTableForClass(class, options.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	volumeName := strings.Join([]string{options.PVC.Namespace, options.PVC.Name, options.PVName}, "-")
+	volumePath := path.Join(p.basePath, volumeName)
+
+	exists, existingGID, err := volumeExists(volumePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var gid int
+	if exists {
+		md, err := readVolumeMetadata(volumePath)
+		if err != nil {
+			return nil, err
+		}
+		if err := validatePreexistingVolume(options, md, volumePath, existingGID); err != nil {
+			return nil, err
+		}
+		gid = int(existingGID)
+	} else {
+		gid, err = gidTable.AllocateNext()
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate a gid for %s: %v", volumePath, err)
+		}
+
+		if err := os.MkdirAll(volumePath, 0771); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", volumePath, err)
+		}
+		if err := os.Chown(volumePath, 0, gid); err != nil {
+			return nil, fmt.Errorf("failed to chown %s to gid %d: %v", volumePath, gid, err)
+		}
+
+		if options.PVC.Spec.DataSource != nil && !p.snapshotsEnabled {
+			return nil, fmt.Errorf("PVC %s/%s has a dataSource but this provisioner was started without --snapshot-class", options.PVC.Namespace, options.PVC.Name)
+		}
+		if restored, err := restoreFromDataSourceIfNeeded(options, volumePath, p.snapshotter); err != nil {
+			return nil, fmt.Errorf("failed to restore %s from its dataSource: %v", volumePath, err)
+		} else if restored != nil {
+			if err := chownTree(volumePath, gid); err != nil {
+				return nil, fmt.Errorf("failed to chown restored volume %s to gid %d: %v", volumePath, gid, err)
+			}
+		}
+
+		if options.Parameters[reuseVolumesParam] == "true" {
+			md := &volumeMetadata{
+				StorageClassName: class,
+				PVCName:          options.PVC.Name,
+				PVCNamespace:     options.PVC.Namespace,
+				GID:              strconv.Itoa(gid),
+			}
+			if err := writeVolumeMetadata(volumePath, md); err != nil {
+				return nil, fmt.Errorf("failed to write volume metadata for %s: %v", volumePath, err)
+			}
+		}
+	}
+
+	requested := options.PVC.Spec.Resources.Requests[v1.ResourceStorage]
+
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        options.PVName,
+			Annotations: map[string]string{pvProvisionerGIDAnnotation: strconv.Itoa(gid)},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: options.PersistentVolumeReclaimPolicy,
+			AccessModes:                   options.PVC.Spec.AccessModes,
+			Capacity: v1.ResourceList{
+				v1.ResourceStorage: requested,
+			},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				NFS: &v1.NFSVolumeSource{
+					Server: p.server,
+					Path:   volumePath,
+				},
+			},
+		},
+	}
+
+	if options.Parameters[enforceQuotaParam] == "true" {
+		p.startQuotaEnforcer(volumePath, newQuotaEnforcer(p.client, p.metrics.providerFor(volumePath), requested, p.recorder, pv, defaultMetricsTTL))
+	}
+
+	return pv, nil
+}
+
+// chownTree recursively chowns every entry under root to gid, since copyTree preserves the
+// restoring process's own uid/gid rather than the volume's newly allocated one.
+func chownTree(root string, gid int) error {
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chown(p, 0, gid)
+	})
+}
+
+// startQuotaEnforcer runs enforcer until either p.stopCh fires or the PV at volumePath is
+// deleted, whichever comes first, so a deleted PV doesn't leave its enforcer polling forever.
+func (p *efsProvisioner) startQuotaEnforcer(volumePath string, enforcer *quotaEnforcer) {
+	p.mu.Lock()
+	stop := make(chan struct{})
+	p.quotaStop[volumePath] = stop
+	p.mu.Unlock()
+
+	go enforcer.Run(mergeStopCh(p.stopCh, stop))
+}
+
+// mergeStopCh returns a channel that closes as soon as either a or b does.
+func mergeStopCh(a <-chan struct{}, b <-chan struct{}) <-chan struct{} {
+	merged := make(chan struct{})
+	go func() {
+		defer close(merged)
+		select {
+		case <-a:
+		case <-b:
+		}
+	}()
+	return merged
+}
+
+// Delete implements controller.Provisioner.
+func (p *efsProvisioner) Delete(volume *v1.PersistentVolume) error {
+	if volume.Spec.NFS == nil {
+		return fmt.Errorf("PV %s has no NFS source, refusing to delete", volume.Name)
+	}
+	volumePath := volume.Spec.NFS.Path
+
+	p.mu.Lock()
+	if stop, ok := p.quotaStop[volumePath]; ok {
+		close(stop)
+		delete(p.quotaStop, volumePath)
+	}
+	p.mu.Unlock()
+
+	p.metrics.Remove(volumePath)
+
+	return os.RemoveAll(volumePath)
+}
+
+// gidTableForClass returns the gid allocator for class, building and reclaiming it from
+// existing volumes the first time a class is provisioned for.
+func (p *efsProvisioner) gidTableForClass(class string, parameters map[string]string) (*allocator.MinMaxAllocator, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if table, ok := p.gidTables[class]; ok {
+		return table, nil
+	}
+
+	min, max, err := gidRangeFromParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := allocator.NewMinMaxAllocator(min, max)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gid allocator for storage class %s: %v", class, err)
+	}
+
+	reclaimer, err := newGIDReclaimer(*gidReclaimerKind, p.basePath, p.client)
+	if err != nil {
+		return nil, err
+	}
+	if err := reclaimer.Reclaim(class, table); err != nil {
+		return nil, fmt.Errorf("failed to reclaim gids for storage class %s: %v", class, err)
+	}
+
+	p.gidTables[class] = table
+	return table, nil
+}
+
+func gidRangeFromParameters(parameters map[string]string) (int, int, error) {
+	min := defaultGidMin
+	max := defaultGidMax
+
+	if v, ok := parameters[gidMinParam]; ok {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s %q: %v", gidMinParam, v, err)
+		}
+		min = parsed
+	}
+	if v, ok := parameters[gidMaxParam]; ok {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s %q: %v", gidMaxParam, v, err)
+		}
+		max = parsed
+	}
+
+	if min > max {
+		return 0, 0, fmt.Errorf("%s (%d) must not be greater than %s (%d)", gidMinParam, min, gidMaxParam, max)
+	}
+
+	return min, max, nil
+}
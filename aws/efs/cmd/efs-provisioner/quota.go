@@ -0,0 +1,90 @@
+package main
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+)
+
+// exceededAnnotation is set on a PV once its provisioned directory is found to be using
+// more than the PVC's requested capacity, so callers (e.g. a readonly-remount admission
+// webhook) can react to it without polling the metrics themselves.
+const exceededAnnotation = "efs-provisioner.io/quota-exceeded"
+
+// quotaEnforcer periodically compares a volume's measured usage against its requested
+// capacity and reports the PV as exceeded once it goes over, for StorageClasses that set
+// enforceQuota: true. The provisioner itself is not capable of actually reclaiming space on
+// a shared EFS filesystem, so enforcement is advisory: an Event and an annotation, not a hard quota.
+type quotaEnforcer struct {
+	client    kubernetes.Interface
+	metrics   MetricsProvider
+	requested resource.Quantity
+	recorder  record.EventRecorder
+	pv        *v1.PersistentVolume
+	interval  time.Duration
+}
+
+func newQuotaEnforcer(client kubernetes.Interface, metrics MetricsProvider, requested resource.Quantity, recorder record.EventRecorder, pv *v1.PersistentVolume, interval time.Duration) *quotaEnforcer {
+	return &quotaEnforcer{
+		client:    client,
+		metrics:   metrics,
+		requested: requested,
+		recorder:  recorder,
+		pv:        pv,
+		interval:  interval,
+	}
+}
+
+// Run polls for quota violations until stopCh is closed. It's meant to be started in its own
+// goroutine per volume that has enforceQuota set.
+func (q *quotaEnforcer) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			q.checkOnce()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (q *quotaEnforcer) checkOnce() {
+	metrics, err := q.metrics.GetMetrics()
+	if err != nil {
+		glog.Warningf("quota enforcer: failed to get metrics for %s: %v", q.pv.Name, err)
+		return
+	}
+
+	if metrics.Used == nil || metrics.Used.Cmp(q.requested) <= 0 {
+		return
+	}
+
+	if q.pv.Annotations[exceededAnnotation] == "true" {
+		// already reported, don't spam an Event every interval
+		return
+	}
+
+	glog.Warningf("volume %s is using %s, which exceeds its requested capacity of %s", q.pv.Name, metrics.Used.String(), q.requested.String())
+
+	pv := q.pv.DeepCopy()
+	if pv.Annotations == nil {
+		pv.Annotations = map[string]string{}
+	}
+	pv.Annotations[exceededAnnotation] = "true"
+
+	updated, err := q.client.CoreV1().PersistentVolumes().Update(pv)
+	if err != nil {
+		glog.Errorf("quota enforcer: failed to annotate PV %s as exceeded: %v", q.pv.Name, err)
+		return
+	}
+	q.pv = updated
+
+	q.recorder.Eventf(q.pv, v1.EventTypeWarning, "QuotaExceeded", "volume is using %s, which exceeds its requested capacity of %s", metrics.Used.String(), q.requested.String())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestQuotaEnforcerAnnotatesAndFiresEventOnce(t *testing.T) {
+	pv := &v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}}
+
+	client := fake.NewSimpleClientset(pv)
+	recorder := record.NewFakeRecorder(10)
+	metrics := &fakeMetricsProvider{metrics: &VolumeMetrics{Used: resource.NewQuantity(200, resource.BinarySI)}}
+	requested := *resource.NewQuantity(100, resource.BinarySI)
+
+	q := newQuotaEnforcer(client, metrics, requested, recorder, pv, 0)
+
+	q.checkOnce()
+
+	updated, err := client.CoreV1().PersistentVolumes().Get(pv.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get PV: %v", err)
+	}
+	if updated.Annotations[exceededAnnotation] != "true" {
+		t.Errorf("expected PV to be annotated %s=true, got %v", exceededAnnotation, updated.Annotations)
+	}
+
+	select {
+	case <-recorder.Events:
+	default:
+		t.Error("expected an Event to be fired for the newly-exceeded volume")
+	}
+
+	// a second check, now that the in-memory pv reflects the annotation, must not annotate or
+	// fire again
+	q.checkOnce()
+
+	select {
+	case e := <-recorder.Events:
+		t.Errorf("expected no further Event once already marked exceeded, got %q", e)
+	default:
+	}
+}
+
+func TestQuotaEnforcerIgnoresVolumesWithinQuota(t *testing.T) {
+	pv := &v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}}
+
+	client := fake.NewSimpleClientset(pv)
+	recorder := record.NewFakeRecorder(10)
+	metrics := &fakeMetricsProvider{metrics: &VolumeMetrics{Used: resource.NewQuantity(50, resource.BinarySI)}}
+	requested := *resource.NewQuantity(100, resource.BinarySI)
+
+	q := newQuotaEnforcer(client, metrics, requested, recorder, pv, 0)
+	q.checkOnce()
+
+	updated, err := client.CoreV1().PersistentVolumes().Get(pv.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get PV: %v", err)
+	}
+	if updated.Annotations[exceededAnnotation] == "true" {
+		t.Error("expected a volume within quota not to be annotated as exceeded")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	reclaimDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "efs_provisioner",
+		Name:      "reclaim_duration_seconds",
+		Help:      "Time it took fileSystemReclaimer.Reclaim to rebuild the gid table for a storage class",
+	}, []string{"storage_class"})
+	reclaimDirsScanned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "efs_provisioner",
+		Name:      "reclaim_directories_scanned_total",
+		Help:      "Number of directories examined while rebuilding the gid table",
+	}, []string{"storage_class"})
+	reclaimMetadataErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "efs_provisioner",
+		Name:      "reclaim_metadata_errors_total",
+		Help:      "Number of directories whose volume metadata could not be read or parsed while rebuilding the gid table",
+	}, []string{"storage_class"})
+	reclaimGIDConflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "efs_provisioner",
+		Name:      "reclaim_gid_conflicts_total",
+		Help:      "Number of gids found in volume metadata that were already allocated while rebuilding the gid table",
+	}, []string{"storage_class"})
+)
+
+func init() {
+	prometheus.MustRegister(reclaimDurationSeconds, reclaimDirsScanned, reclaimMetadataErrors, reclaimGIDConflicts)
+}
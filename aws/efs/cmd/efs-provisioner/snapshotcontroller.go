@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/pkg/apis/volumesnapshot/v1"
+	snapshotclientset "github.com/kubernetes-csi/external-snapshotter/pkg/client/clientset/versioned"
+	snapshotinformers "github.com/kubernetes-csi/external-snapshotter/pkg/client/informers/externalversions"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// snapshotController watches VolumeSnapshot objects that reference this provisioner's
+// VolumeSnapshotClass, takes a filesystem snapshot of the source PVC's directory for each
+// one, and creates the VolumeSnapshotContent that binds the result back to it.
+type snapshotController struct {
+	provisionerName string
+	className       string
+	basePath        string
+	client          snapshotclientset.Interface
+	snapshotter     snapshotter
+
+	informer cache.SharedIndexInformer
+	queue    workqueue.RateLimitingInterface
+}
+
+func newSnapshotController(provisionerName, className, basePath string, client snapshotclientset.Interface, snapshotter snapshotter, resync time.Duration) *snapshotController {
+	factory := snapshotinformers.NewSharedInformerFactory(client, resync)
+	informer := factory.Snapshot().V1().VolumeSnapshots().Informer()
+
+	c := &snapshotController{
+		provisionerName: provisionerName,
+		className:       className,
+		basePath:        basePath,
+		client:          client,
+		snapshotter:     snapshotter,
+		informer:        informer,
+		queue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "volumesnapshots"),
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(old, new interface{}) { c.enqueue(new) },
+	})
+
+	return c
+}
+
+func (c *snapshotController) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		glog.Errorf("failed to get key for %v: %v", obj, err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// Run starts the informer and processes the work queue until stopCh is closed.
+func (c *snapshotController) Run(stopCh <-chan struct{}) {
+	defer c.queue.ShutDown()
+
+	go c.informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
+		glog.Error("timed out waiting for volumesnapshot informer cache to sync")
+		return
+	}
+
+	go wait.Until(c.runWorker, time.Second, stopCh)
+
+	<-stopCh
+}
+
+func (c *snapshotController) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *snapshotController) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(key.(string)); err != nil {
+		glog.Errorf("failed to sync volumesnapshot %s: %v", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *snapshotController) sync(key string) error {
+	obj, exists, err := c.informer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		// deleted; nothing is done here. This controller never calls snapshotter.Delete, so
+		// the snapshot's files under .snapshots are left in place.
+		return nil
+	}
+
+	snap := obj.(*snapshotv1.VolumeSnapshot)
+	if snap.Spec.VolumeSnapshotClassName == nil || *snap.Spec.VolumeSnapshotClassName != c.className {
+		return nil
+	}
+	if snap.Status != nil && snap.Status.ReadyToUse != nil && *snap.Status.ReadyToUse {
+		return nil
+	}
+	if snap.Spec.Source.PersistentVolumeClaimName == nil {
+		return fmt.Errorf("volumesnapshot %s/%s has no source PVC", snap.Namespace, snap.Name)
+	}
+
+	sourcePath, md, err := findVolumeDirectory(c.basePath, snap.Namespace, *snap.Spec.Source.PersistentVolumeClaimName)
+	if err != nil {
+		return err
+	}
+
+	meta := snapshotMetadata{
+		SourcePVCName: md.PVCName,
+		GID:           md.GID,
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if err := c.snapshotter.Snapshot(string(snap.UID), snap.Name, sourcePath, meta); err != nil {
+		return err
+	}
+
+	contentName, err := c.createVolumeSnapshotContent(snap)
+	if err != nil {
+		return err
+	}
+
+	return c.markReady(snap, contentName)
+}
+
+// createVolumeSnapshotContent creates the VolumeSnapshotContent that binds snap to the
+// filesystem snapshot just taken for it, and returns its name. The snapshot handle is
+// "<snapshot-uid>/<snap-name>": sync passes the VolumeSnapshot's own UID, not the source
+// PVC's UID, as the pvcUID argument of snapshotter.Snapshot, so the filesystem snapshot lives
+// under .snapshots/<snapshot-uid>/<snap-name>.
+func (c *snapshotController) createVolumeSnapshotContent(snap *snapshotv1.VolumeSnapshot) (string, error) {
+	contentName := fmt.Sprintf("snapcontent-%s", snap.UID)
+	handle := fmt.Sprintf("%s/%s", snap.UID, snap.Name)
+	ready := true
+
+	content := &snapshotv1.VolumeSnapshotContent{
+		ObjectMeta: metav1.ObjectMeta{Name: contentName},
+		Spec: snapshotv1.VolumeSnapshotContentSpec{
+			VolumeSnapshotRef: v1.ObjectReference{
+				Name:      snap.Name,
+				Namespace: snap.Namespace,
+				UID:       snap.UID,
+			},
+			Driver:         c.provisionerName,
+			DeletionPolicy: snapshotv1.VolumeSnapshotContentDelete,
+			Source: snapshotv1.VolumeSnapshotContentSource{
+				SnapshotHandle: &handle,
+			},
+		},
+		Status: &snapshotv1.VolumeSnapshotContentStatus{
+			SnapshotHandle: &handle,
+			ReadyToUse:     &ready,
+		},
+	}
+
+	_, err := c.client.SnapshotV1().VolumeSnapshotContents().Create(content)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("failed to create VolumeSnapshotContent %s: %v", contentName, err)
+	}
+
+	return contentName, nil
+}
+
+// markReady patches snap's status to ReadyToUse once its VolumeSnapshotContent exists, so the
+// next informer resync's ReadyToUse check in sync short-circuits instead of re-snapshotting.
+func (c *snapshotController) markReady(snap *snapshotv1.VolumeSnapshot, contentName string) error {
+	updated := snap.DeepCopy()
+	ready := true
+	updated.Status = &snapshotv1.VolumeSnapshotStatus{
+		BoundVolumeSnapshotContentName: &contentName,
+		ReadyToUse:                     &ready,
+	}
+
+	if _, err := c.client.SnapshotV1().VolumeSnapshots(snap.Namespace).UpdateStatus(updated); err != nil {
+		return fmt.Errorf("failed to mark volumesnapshot %s/%s ready: %v", snap.Namespace, snap.Name, err)
+	}
+
+	return nil
+}
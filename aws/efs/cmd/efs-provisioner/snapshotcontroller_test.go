@@ -0,0 +1,126 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/pkg/apis/volumesnapshot/v1"
+	snapshotfake "github.com/kubernetes-csi/external-snapshotter/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestVolumeSnapshot(className, pvcName string) *snapshotv1.VolumeSnapshot {
+	return &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: "snap-1", Namespace: "default", UID: types.UID("uid-1")},
+		Spec: snapshotv1.VolumeSnapshotSpec{
+			VolumeSnapshotClassName: &className,
+			Source:                  snapshotv1.VolumeSnapshotSource{PersistentVolumeClaimName: &pvcName},
+		},
+	}
+}
+
+// TestSnapshotControllerSyncCreatesContentAndMarksReady exercises sync's happy path: a fresh
+// VolumeSnapshot for a known PVC gets a filesystem snapshot, a VolumeSnapshotContent, and its
+// status patched to ReadyToUse.
+func TestSnapshotControllerSyncCreatesContentAndMarksReady(t *testing.T) {
+	const class = "test-class"
+
+	base, err := ioutil.TempDir("", "snapshotcontroller-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	sourceDir := filepath.Join(base, "source")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	md := &volumeMetadata{
+		StorageClassName: class,
+		PVCName:          "my-pvc",
+		PVCNamespace:     "default",
+		GID:              "2000",
+	}
+	if err := writeVolumeMetadata(sourceDir, md); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := newTestVolumeSnapshot(class, "my-pvc")
+	client := snapshotfake.NewSimpleClientset(snap)
+	c := newSnapshotController("test-provisioner", class, base, client, newFSSnapshotter(base), 0)
+
+	if err := c.informer.GetStore().Add(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	key := "default/snap-1"
+	if err := c.sync(key); err != nil {
+		t.Fatalf("sync returned error: %v", err)
+	}
+
+	contents, err := client.SnapshotV1().VolumeSnapshotContents().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents.Items) != 1 {
+		t.Fatalf("expected 1 VolumeSnapshotContent to be created, got %d", len(contents.Items))
+	}
+
+	updated, err := client.SnapshotV1().VolumeSnapshots("default").Get("snap-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status == nil || updated.Status.ReadyToUse == nil || !*updated.Status.ReadyToUse {
+		t.Fatal("expected VolumeSnapshot status to be marked ReadyToUse")
+	}
+
+	// simulate the informer's cache catching up with the status patch just made, the way a
+	// real resync would; sync must then short-circuit instead of snapshotting again
+	if err := c.informer.GetStore().Update(updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.sync(key); err != nil {
+		t.Fatalf("second sync returned error: %v", err)
+	}
+
+	contentsAfter, err := client.SnapshotV1().VolumeSnapshotContents().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contentsAfter.Items) != 1 {
+		t.Errorf("expected sync to be a no-op once ReadyToUse, got %d VolumeSnapshotContents", len(contentsAfter.Items))
+	}
+}
+
+// TestSnapshotControllerSyncIgnoresOtherSnapshotClasses ensures sync leaves VolumeSnapshots
+// for a different VolumeSnapshotClass alone.
+func TestSnapshotControllerSyncIgnoresOtherSnapshotClasses(t *testing.T) {
+	base, err := ioutil.TempDir("", "snapshotcontroller-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	snap := newTestVolumeSnapshot("other-class", "my-pvc")
+	client := snapshotfake.NewSimpleClientset(snap)
+	c := newSnapshotController("test-provisioner", "test-class", base, client, newFSSnapshotter(base), 0)
+
+	if err := c.informer.GetStore().Add(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.sync("default/snap-1"); err != nil {
+		t.Fatalf("sync returned error: %v", err)
+	}
+
+	contents, err := client.SnapshotV1().VolumeSnapshotContents().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents.Items) != 0 {
+		t.Errorf("expected no VolumeSnapshotContent for a snapshot of a different class, got %d", len(contents.Items))
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+// snapshotter takes and restores point-in-time copies of a provisioned directory. The
+// filesystem implementation (fsSnapshotter) stores copies under BasePath/.snapshots; the
+// interface exists so a downstream fork could ship snapshots off to object storage instead.
+type snapshotter interface {
+	// Snapshot copies the contents of sourcePath into a new snapshot named snapName, scoped
+	// under pvcUID, recording meta alongside it.
+	Snapshot(pvcUID, snapName, sourcePath string, meta snapshotMetadata) error
+
+	// Restore copies a previously taken snapshot's contents into destPath, which must already
+	// exist and be empty, and returns the metadata that was recorded when it was taken.
+	Restore(pvcUID, snapName, destPath string) (*snapshotMetadata, error)
+
+	// Delete removes a previously taken snapshot.
+	Delete(pvcUID, snapName string) error
+
+	// Locate finds which pvcUID a snapshot named snapName was taken under, so callers that
+	// only know the VolumeSnapshot name (e.g. Provision restoring from a dataSource) can find
+	// it without having to resolve the source PVC's UID themselves.
+	Locate(snapName string) (pvcUID string, err error)
+}
+
+// snapshotMetadata is the sidecar record written next to each snapshot.
+type snapshotMetadata struct {
+	SourcePVCName string `json:"sourcePVCName"`
+	GID           string `json:"gid"`
+	Timestamp     string `json:"timestamp"`
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+)
+
+// findVolumeDirectory scans basePath for the provisioned directory whose metadata matches
+// namespace/pvcName.
+func findVolumeDirectory(basePath, namespace, pvcName string) (string, *volumeMetadata, error) {
+	entries, err := ioutil.ReadDir(basePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list %s: %v", basePath, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == snapshotsDirName {
+			continue
+		}
+
+		dir := path.Join(basePath, entry.Name())
+
+		md, err := readVolumeMetadata(dir)
+		if err != nil || md == nil {
+			continue
+		}
+
+		if md.PVCNamespace == namespace && md.PVCName == pvcName {
+			return dir, md, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("no provisioned directory found under %s for PVC %s/%s", basePath, namespace, pvcName)
+}